@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HostLimiter hands out per-host concurrency slots so that one slow host
+// can't starve the slots meant for the other hosts in flight. A per-host
+// override replaces the default limit for that host.
+type HostLimiter struct {
+	mu        sync.Mutex
+	def       int
+	overrides map[string]int
+	sems      map[string]chan struct{}
+}
+
+// NewHostLimiter returns a HostLimiter granting def concurrent slots per
+// host, or the override in overrides when one exists for that host. A
+// non-positive def falls back to 10.
+func NewHostLimiter(def int, overrides map[string]int) *HostLimiter {
+	if def <= 0 {
+		def = 10
+	}
+	if overrides == nil {
+		overrides = map[string]int{}
+	}
+	return &HostLimiter{def: def, overrides: overrides, sems: make(map[string]chan struct{})}
+}
+
+func (l *HostLimiter) semFor(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.sems[host]
+	if ok {
+		return sem
+	}
+	limit := l.def
+	if n, ok := l.overrides[host]; ok && n > 0 {
+		limit = n
+	}
+	sem = make(chan struct{}, limit)
+	l.sems[host] = sem
+	return sem
+}
+
+// Acquire blocks until a slot for host is available or ctx is done.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) error {
+	select {
+	case l.semFor(host) <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release returns a slot for host. It must be called once per successful
+// Acquire for that host.
+func (l *HostLimiter) Release(host string) {
+	<-l.semFor(host)
+}
+
+// ClientConfig tunes NewClient.
+type ClientConfig struct {
+	MaxIdleConns    int
+	IdleConnTimeout time.Duration
+
+	// DefaultHostConcurrency bounds in-flight requests per host; a
+	// non-positive value falls back to MaxConcurrentStreams.
+	DefaultHostConcurrency int
+	HostConcurrency        map[string]int
+
+	// MaxConcurrentStreams is not passed to the x/net/http2 client
+	// transport, which has no stream cap of its own. It bounds in-flight
+	// requests per host only when DefaultHostConcurrency is non-positive,
+	// by becoming the HostLimiter's default.
+	MaxConcurrentStreams int
+	ReadIdleTimeout      time.Duration
+	PingTimeout          time.Duration
+}
+
+// Client bundles an HTTP/2-capable *http.Client with a HostLimiter so that
+// delivery workers can share a connection pool without one slow host
+// exhausting the capacity meant for the other nine.
+type Client struct {
+	HTTP    *http.Client
+	Limiter *HostLimiter
+
+	MaxConcurrentStreams int
+	ReadIdleTimeout      time.Duration
+	PingTimeout          time.Duration
+}
+
+// NewClient builds a Client whose transport negotiates h2 when the server
+// supports it, tuned with the keep-alive settings in cfg, and whose
+// HostLimiter enforces cfg's per-host concurrency caps.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        cfg.MaxIdleConns,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if transport.MaxIdleConns <= 0 {
+		transport.MaxIdleConns = 100
+	}
+	if transport.IdleConnTimeout <= 0 {
+		transport.IdleConnTimeout = 90 * time.Second
+	}
+
+	h2Transport, err := http2.ConfigureTransports(transport)
+	if err != nil {
+		return nil, fmt.Errorf("configuring http2 transport: %w", err)
+	}
+	if cfg.ReadIdleTimeout > 0 {
+		h2Transport.ReadIdleTimeout = cfg.ReadIdleTimeout
+	}
+	if cfg.PingTimeout > 0 {
+		h2Transport.PingTimeout = cfg.PingTimeout
+	}
+
+	defaultConcurrency := cfg.DefaultHostConcurrency
+	if defaultConcurrency <= 0 {
+		defaultConcurrency = cfg.MaxConcurrentStreams
+	}
+
+	return &Client{
+		HTTP:                 &http.Client{Transport: transport},
+		Limiter:              NewHostLimiter(defaultConcurrency, cfg.HostConcurrency),
+		MaxConcurrentStreams: cfg.MaxConcurrentStreams,
+		ReadIdleTimeout:      h2Transport.ReadIdleTimeout,
+		PingTimeout:          h2Transport.PingTimeout,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CrashReport describes a panic recovered by RecoverMiddleware.
+type CrashReport struct {
+	WorkerID int
+	JobID    string
+	Time     time.Time
+	Reason   any
+	Stack    []byte
+}
+
+func newCrashReport(workerID int, jobID string, reason any) CrashReport {
+	return CrashReport{
+		WorkerID: workerID,
+		JobID:    jobID,
+		Time:     time.Now(),
+		Reason:   reason,
+		Stack:    debug.Stack(),
+	}
+}
+
+// CrashSink receives crash reports. Users can plug in their own sink to
+// forward crashes wherever their operations tooling expects them.
+type CrashSink interface {
+	Capture(CrashReport) error
+}
+
+// DiskCrashSink writes crash reports to a content-addressed path under Dir,
+// atomically (write to a temp file, then rename), and evicts the oldest
+// reports once the directory holds more than MaxEntries, so crash history
+// survives process restarts without growing unbounded.
+type DiskCrashSink struct {
+	Dir        string
+	MaxEntries int
+
+	mu sync.Mutex
+}
+
+// NewDiskCrashSink returns a DiskCrashSink rooted at dir. A non-positive
+// maxEntries falls back to 1000.
+func NewDiskCrashSink(dir string, maxEntries int) *DiskCrashSink {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &DiskCrashSink{Dir: dir, MaxEntries: maxEntries}
+}
+
+// Capture implements CrashSink.
+func (s *DiskCrashSink) Capture(report CrashReport) error {
+	data, err := json.Marshal(struct {
+		WorkerID int       `json:"worker_id"`
+		JobID    string    `json:"job_id"`
+		Time     time.Time `json:"time"`
+		Reason   string    `json:"reason"`
+		Stack    string    `json:"stack"`
+	}{
+		WorkerID: report.WorkerID,
+		JobID:    report.JobID,
+		Time:     report.Time,
+		Reason:   fmt.Sprint(report.Reason),
+		Stack:    string(report.Stack),
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling crash report: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating crash dir: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	finalPath := filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+
+	tmp, err := os.CreateTemp(s.Dir, ".crash-*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp crash file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing crash file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("closing crash file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), finalPath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("renaming crash file: %w", err)
+	}
+
+	return s.evictLocked()
+}
+
+// evictLocked removes the oldest crash files once the directory holds more
+// than MaxEntries. Callers must hold s.mu.
+func (s *DiskCrashSink) evictLocked() error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return fmt.Errorf("reading crash dir: %w", err)
+	}
+
+	type fileInfo struct {
+		name    string
+		modTime time.Time
+	}
+	var files []fileInfo
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo{name: e.Name(), modTime: info.ModTime()})
+	}
+	if len(files) <= s.MaxEntries {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files[:len(files)-s.MaxEntries] {
+		if err := os.Remove(filepath.Join(s.Dir, f.name)); err != nil {
+			return fmt.Errorf("evicting crash file %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// SentryCrashSink POSTs crash reports as JSON to a Sentry-compatible HTTP
+// endpoint.
+type SentryCrashSink struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewSentryCrashSink returns a SentryCrashSink posting to endpoint. A nil
+// client falls back to http.DefaultClient.
+func NewSentryCrashSink(endpoint string, client *http.Client) *SentryCrashSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SentryCrashSink{Endpoint: endpoint, Client: client}
+}
+
+// Capture implements CrashSink.
+func (s *SentryCrashSink) Capture(report CrashReport) error {
+	body, err := json.Marshal(struct {
+		Message   string         `json:"message"`
+		Timestamp time.Time      `json:"timestamp"`
+		Extra     map[string]any `json:"extra"`
+	}{
+		Message:   fmt.Sprint(report.Reason),
+		Timestamp: report.Time,
+		Extra: map[string]any{
+			"worker_id": report.WorkerID,
+			"job_id":    report.JobID,
+			"stack":     string(report.Stack),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling sentry payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to sentry endpoint: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("sentry endpoint returned status %d", res.StatusCode)
+	}
+	return nil
+}
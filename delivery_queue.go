@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// DeliveryJob is a unit of work for a sender worker: a payload to POST to a
+// target URL. TargetID groups jobs so that all pending work for a host can be
+// cancelled in one call (e.g. once the host is marked bad).
+type DeliveryJob struct {
+	ID       string
+	TargetID string
+	URL      string
+	Payload  PayLoad
+	Attempt  int
+
+	// Ctx is the context the job was originally enqueued with. Workers derive
+	// their per-attempt context from this one (via context.WithTimeout) so
+	// that values set by the original caller survive re-enqueues.
+	Ctx context.Context
+}
+
+// DeliveryQueue is a durable, in-memory queue of pending DeliveryJobs. Jobs
+// are indexed by TargetID so callers can drop everything bound for a
+// particular host without walking the whole queue.
+type DeliveryQueue struct {
+	mu       sync.Mutex
+	pending  []*DeliveryJob
+	byTarget map[string]map[string]*DeliveryJob // TargetID -> job ID -> job
+	notify   chan struct{}
+
+	outstanding sync.WaitGroup
+}
+
+// NewDeliveryQueue returns an empty, ready-to-use DeliveryQueue.
+func NewDeliveryQueue() *DeliveryQueue {
+	return &DeliveryQueue{
+		byTarget: make(map[string]map[string]*DeliveryJob),
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds job to the queue. If this is the job's first time through the
+// queue (Attempt == 0), it counts against WaitIdle until the job is settled
+// via markSettled. Re-enqueues of an in-flight job (retries) do not add a
+// second count.
+func (q *DeliveryQueue) Enqueue(job *DeliveryJob) {
+	q.mu.Lock()
+	if job.Attempt == 0 {
+		q.outstanding.Add(1)
+	}
+	q.pending = append(q.pending, job)
+	targets, ok := q.byTarget[job.TargetID]
+	if !ok {
+		targets = make(map[string]*DeliveryJob)
+		q.byTarget[job.TargetID] = targets
+	}
+	targets[job.ID] = job
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// CancelTarget removes every pending job bound for targetID and returns how
+// many were dropped. Jobs already popped by a worker are unaffected.
+func (q *DeliveryQueue) CancelTarget(targetID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	targets, ok := q.byTarget[targetID]
+	if !ok || len(targets) == 0 {
+		return 0
+	}
+
+	filtered := q.pending[:0]
+	removed := 0
+	for _, j := range q.pending {
+		if j.TargetID == targetID {
+			removed++
+			continue
+		}
+		filtered = append(filtered, j)
+	}
+	q.pending = filtered
+	delete(q.byTarget, targetID)
+
+	for i := 0; i < removed; i++ {
+		q.outstanding.Done()
+	}
+	return removed
+}
+
+// Len reports how many jobs are currently pending.
+func (q *DeliveryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// pop removes and returns the oldest pending job, if any.
+func (q *DeliveryQueue) pop() (*DeliveryJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, false
+	}
+	job := q.pending[0]
+	q.pending = q.pending[1:]
+	if targets, ok := q.byTarget[job.TargetID]; ok {
+		delete(targets, job.ID)
+		if len(targets) == 0 {
+			delete(q.byTarget, job.TargetID)
+		}
+	}
+	return job, true
+}
+
+// Wait blocks until a job is available or ctx is cancelled; a wake-up that
+// finds the queue empty keeps waiting. It returns false once ctx.Done() fires.
+func (q *DeliveryQueue) Wait(ctx context.Context) (*DeliveryJob, bool) {
+	for {
+		if job, ok := q.pop(); ok {
+			return job, true
+		}
+		select {
+		case <-q.notify:
+			continue
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// markSettled records that a job (identified by its first enqueue) has
+// reached a terminal state — delivered, dropped, or exhausted its attempts —
+// and will not be re-enqueued.
+func (q *DeliveryQueue) markSettled() {
+	q.outstanding.Done()
+}
+
+// WaitIdle blocks until every job that has ever been enqueued has settled.
+func (q *DeliveryQueue) WaitIdle() {
+	q.outstanding.Wait()
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedResponse is the cacheable subset of an *http.Response: status,
+// headers, and a fully-buffered body.
+type cachedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+func (c *cachedResponse) size() int {
+	n := len(c.body)
+	for k, vs := range c.header {
+		n += len(k)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	return n
+}
+
+func (c *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(c.status),
+		StatusCode:    c.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+type cacheEntry struct {
+	key  string
+	resp *cachedResponse
+}
+
+// flightCall tracks a single in-flight upstream fetch so concurrent
+// RoundTrips for the same key wait on it instead of issuing their own.
+type flightCall struct {
+	done chan struct{}
+	resp *cachedResponse
+	err  error
+}
+
+// CacheStats is a snapshot of a CachingTransport's counters.
+type CacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	BytesServed uint64
+}
+
+// CachingTransport is an http.RoundTripper that sits in front of a base
+// transport and caches responses in a bounded, in-process LRU keyed on
+// method, URL, and request body hash. It honors Cache-Control: max-age /
+// no-store and Expires, and single-flights concurrent misses for the same
+// key so only one upstream request is made.
+type CachingTransport struct {
+	Base http.RoundTripper
+
+	MaxEntries int
+	MaxBytes   int64
+
+	mu         sync.Mutex
+	ll         *list.List
+	items      map[string]*list.Element
+	totalBytes int64
+
+	hits, misses, bytesServed uint64
+
+	flightMu sync.Mutex
+	inFlight map[string]*flightCall
+}
+
+// NewCachingTransport returns a CachingTransport wrapping base. A nil base
+// falls back to http.DefaultTransport. maxEntries and maxBytes bound the
+// cache; non-positive values fall back to defaults of 256 entries / 64MiB.
+func NewCachingTransport(base http.RoundTripper, maxEntries int, maxBytes int64) *CachingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if maxEntries <= 0 {
+		maxEntries = 256
+	}
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	return &CachingTransport{
+		Base:       base,
+		MaxEntries: maxEntries,
+		MaxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		inFlight:   make(map[string]*flightCall),
+	}
+}
+
+// Stats returns a snapshot of the cache's hit/miss/bytes-served counters.
+func (t *CachingTransport) Stats() CacheStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return CacheStats{Hits: t.hits, Misses: t.misses, BytesServed: t.bytesServed}
+}
+
+func cacheKey(req *http.Request) (string, error) {
+	var bodyHash string
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		bodyHash = hex.EncodeToString(sum[:])
+	}
+	return req.Method + " " + req.URL.String() + " " + bodyHash, nil
+}
+
+// freshnessFromHeaders reports how long a response may be cached for,
+// per Cache-Control: max-age / no-store and Expires. The bool return is
+// false when the response must not be cached.
+func freshnessFromHeaders(h http.Header) (time.Duration, bool) {
+	cc := h.Get("Cache-Control")
+	if strings.Contains(cc, "no-store") {
+		return 0, false
+	}
+	for _, part := range strings.Split(cc, ",") {
+		part = strings.TrimSpace(part)
+		if after, ok := strings.CutPrefix(part, "max-age="); ok {
+			secs, err := strconv.Atoi(after)
+			if err == nil && secs > 0 {
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+	if exp := h.Get("Expires"); exp != "" {
+		if t, err := http.ParseTime(exp); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (t *CachingTransport) get(key string) (*cachedResponse, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.resp.expiresAt) {
+		t.removeElementLocked(el)
+		return nil, false
+	}
+	t.ll.MoveToFront(el)
+	t.hits++
+	t.bytesServed += uint64(len(entry.resp.body))
+	return entry.resp, true
+}
+
+func (t *CachingTransport) put(key string, resp *cachedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.items[key]; ok {
+		t.removeElementLocked(el)
+	}
+	entry := &cacheEntry{key: key, resp: resp}
+	t.items[key] = t.ll.PushFront(entry)
+	t.totalBytes += int64(resp.size())
+
+	for (len(t.items) > t.MaxEntries || t.totalBytes > t.MaxBytes) && t.ll.Len() > 0 {
+		t.removeElementLocked(t.ll.Back())
+	}
+}
+
+func (t *CachingTransport) removeElementLocked(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	t.ll.Remove(el)
+	delete(t.items, entry.key)
+	t.totalBytes -= int64(entry.resp.size())
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := cacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := t.get(key); ok {
+		return cached.toResponse(req), nil
+	}
+
+	t.flightMu.Lock()
+	if call, ok := t.inFlight[key]; ok {
+		t.flightMu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		t.mu.Lock()
+		t.hits++
+		t.bytesServed += uint64(len(call.resp.body))
+		t.mu.Unlock()
+		return call.resp.toResponse(req), nil
+	}
+	call := &flightCall{done: make(chan struct{})}
+	t.inFlight[key] = call
+	t.flightMu.Unlock()
+
+	resp, body, err := t.fetch(req)
+
+	t.flightMu.Lock()
+	delete(t.inFlight, key)
+	t.flightMu.Unlock()
+
+	if err != nil {
+		call.err = err
+		close(call.done)
+		return nil, err
+	}
+
+	cached := &cachedResponse{status: resp.StatusCode, header: resp.Header.Clone(), body: body}
+	if ttl, ok := freshnessFromHeaders(resp.Header); ok {
+		cached.expiresAt = time.Now().Add(ttl)
+		t.put(key, cached)
+	}
+
+	call.resp = cached
+	close(call.done)
+
+	return cached.toResponse(req), nil
+}
+
+// fetch performs the actual upstream round trip and buffers the body so it
+// can be replayed to both the caller and the cache.
+func (t *CachingTransport) fetch(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := t.Base.RoundTrip(req)
+
+	t.mu.Lock()
+	t.misses++
+	t.mu.Unlock()
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
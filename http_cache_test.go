@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport is a fake http.RoundTripper that counts how many times
+// it's invoked and sleeps before responding, so tests can force concurrent
+// callers to overlap.
+type countingTransport struct {
+	calls  int32
+	delay  time.Duration
+	header http.Header
+	body   []byte
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.calls, 1)
+	time.Sleep(c.delay)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     c.header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader(c.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestCachingTransportSingleFlightDedupsConcurrentMisses(t *testing.T) {
+	base := &countingTransport{
+		delay:  20 * time.Millisecond,
+		header: http.Header{"Cache-Control": []string{"max-age=60"}},
+		body:   []byte("hello"),
+	}
+	ct := NewCachingTransport(base, 0, 0)
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/thing", nil)
+			res, err := ct.RoundTrip(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(body) != "hello" {
+				errs <- fmt.Errorf("got body %q, want %q", body, "hello")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if calls := atomic.LoadInt32(&base.calls); calls != 1 {
+		t.Fatalf("base transport was called %d times, want exactly 1", calls)
+	}
+
+	stats := ct.Stats()
+	if stats.Misses != 1 {
+		t.Fatalf("Stats().Misses = %d, want 1", stats.Misses)
+	}
+	if stats.Hits != n-1 {
+		t.Fatalf("Stats().Hits = %d, want %d", stats.Hits, n-1)
+	}
+}
+
+func TestCachingTransportGetExpiresEntries(t *testing.T) {
+	ct := NewCachingTransport(nil, 0, 0)
+	ct.put("key", &cachedResponse{
+		status:    http.StatusOK,
+		header:    http.Header{},
+		body:      []byte("cached"),
+		expiresAt: time.Now().Add(20 * time.Millisecond),
+	})
+
+	if _, ok := ct.get("key"); !ok {
+		t.Fatal("expected a cache hit before the entry expires")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := ct.get("key"); ok {
+		t.Fatal("expected a cache miss once the entry has expired")
+	}
+}
+
+func TestFreshnessFromHeaders(t *testing.T) {
+	cases := []struct {
+		name      string
+		header    http.Header
+		wantCache bool
+	}{
+		{"no-store wins", http.Header{"Cache-Control": []string{"max-age=60, no-store"}}, false},
+		{"max-age present", http.Header{"Cache-Control": []string{"max-age=60"}}, true},
+		{"no freshness info", http.Header{}, false},
+		{"expires in the future", http.Header{"Expires": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}, true},
+		{"expires in the past", http.Header{"Expires": []string{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, ok := freshnessFromHeaders(c.header); ok != c.wantCache {
+				t.Fatalf("freshnessFromHeaders(%v) cacheable = %v, want %v", c.header, ok, c.wantCache)
+			}
+		})
+	}
+}
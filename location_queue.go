@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hash returns a normalized form of the Location's URL suitable for
+// deduplication: lowercased scheme and host with any trailing slash on the
+// path trimmed. Callers should validate the URL (see ValidateURL) before
+// hashing.
+func (l Location) Hash() string {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return l.URL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
+
+// LocationQueue is a ring-buffer queue of Locations that drops duplicates:
+// a Location whose Hash() has been pushed within the TTL window is silently
+// ignored rather than re-added, so repeated entries in input.txt (or added
+// later from other sources) are only dispatched once per window.
+type LocationQueue struct {
+	mu    sync.Mutex
+	ring  []Location
+	head  int
+	tail  int
+	count int
+
+	seen map[string]time.Time // hash -> expiry
+	ttl  time.Duration
+}
+
+// NewLocationQueue returns an empty LocationQueue with the given initial
+// capacity (grown as needed) and dedup TTL.
+func NewLocationQueue(capacity int, ttl time.Duration) *LocationQueue {
+	if capacity <= 0 {
+		capacity = 64
+	}
+	return &LocationQueue{
+		ring: make([]Location, capacity),
+		seen: make(map[string]time.Time),
+		ttl:  ttl,
+	}
+}
+
+// Push validates loc's URL and adds it to the queue, unless its hash was
+// already seen within the TTL window, in which case added is false and err
+// is nil. err is non-nil only when loc.URL fails validation.
+func (q *LocationQueue) Push(loc Location) (added bool, err error) {
+	validated, err := ValidateURL(loc.URL)
+	if err != nil {
+		return false, err
+	}
+	loc.URL = validated
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	hash := loc.Hash()
+	now := time.Now()
+	if expiry, ok := q.seen[hash]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	q.seen[hash] = now.Add(q.ttl)
+
+	if q.count == len(q.ring) {
+		q.growLocked()
+	}
+	q.ring[q.tail] = loc
+	q.tail = (q.tail + 1) % len(q.ring)
+	q.count++
+	return true, nil
+}
+
+func (q *LocationQueue) growLocked() {
+	bigger := make([]Location, len(q.ring)*2)
+	n := copy(bigger, q.ring[q.head:])
+	n += copy(bigger[n:], q.ring[:q.head])
+	q.ring = bigger
+	q.head = 0
+	q.tail = n
+}
+
+// Pop removes and returns the oldest pending Location, if any.
+func (q *LocationQueue) Pop() (Location, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.count == 0 {
+		return Location{}, false
+	}
+	loc := q.ring[q.head]
+	q.head = (q.head + 1) % len(q.ring)
+	q.count--
+	return loc, true
+}
+
+// Len reports how many Locations are currently pending.
+func (q *LocationQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+)
+
+// logger is the process-wide structured logger for delivery events.
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+// logJobEvent emits a structured log line for a delivery job attempt,
+// stamped with the worker ID, job ID, target host, attempt number, and the
+// file:line of the call site so failures across many worker goroutines can
+// be correlated.
+func logJobEvent(level slog.Level, msg string, workerID int, jobID, host string, attempt int, err error) {
+	_, file, line, ok := runtime.Caller(1)
+	source := "unknown"
+	if ok {
+		source = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	attrs := []any{
+		slog.Int("worker", workerID),
+		slog.String("job", jobID),
+		slog.String("host", host),
+		slog.Int("attempt", attempt),
+		slog.String("source", source),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	logger.Log(context.Background(), level, msg, attrs...)
+}
+
+// RecoverMiddleware runs fn, recovering any panic, recording the stack, and
+// forwarding a CrashReport to sink. If sink is nil the panic is only logged.
+func RecoverMiddleware(sink CrashSink, workerID int, jobID string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			report := newCrashReport(workerID, jobID, r)
+			logger.Error("recovered panic in worker", slog.Int("worker", workerID), slog.String("job", jobID), slog.Any("reason", r))
+			if sink == nil {
+				return
+			}
+			if err := sink.Capture(report); err != nil {
+				logger.Error("failed to capture crash report", slog.Int("worker", workerID), slog.Any("error", err))
+			}
+		}
+	}()
+	fn()
+}
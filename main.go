@@ -4,35 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"sync"
 	"time"
 )
 
-// Semaphore to limit the number of concurrent goroutines
-type Semaphore struct {
-	ch chan struct{}
-}
-
-func NewSemaphore(limit int) *Semaphore {
-	return &Semaphore{
-		ch: make(chan struct{}, limit),
-	}
-}
-
-func (s *Semaphore) Acquire() {
-	s.ch <- struct{}{}
-}
-
-func (s *Semaphore) Release() {
-	<-s.ch
-}
-
 // Location struct to hold parsed data
 type Location struct {
 	URL string `json:"location"`
@@ -73,51 +53,10 @@ func createBaseRequest(ctx context.Context, payload PayLoad) (*http.Request, err
 	return req, nil
 }
 
-// Goroutine function to process a location
-func processLocation(semaphore *Semaphore, wg *sync.WaitGroup, loc Location, baseRequest *http.Request, client *http.Client) {
-	defer wg.Done()           // Ensure the counter is decremented when the goroutine completes
-	semaphore.Acquire()       // Acquire a semaphore slot to control concurrency
-	defer semaphore.Release() // Release the semaphore slot when done
-
-	// Validate the URL
-	validatedURL, err := ValidateURL(loc.URL)
-	if err != nil {
-		log.Printf("Invalid URL: %s", loc.URL)
-		return
-	}
-
-	if validatedURL == "https://bar.com" {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Set a 5-second timeout
-		defer cancel()                                                          // Ensure the context is canceled
-
-		// Clone the base request and update the URL
-		req := baseRequest.Clone(ctx)
-		req.URL, _ = url.Parse(validatedURL) // Update the URL in the cloned request
-
-		res, err := client.Do(req) // Send the POST request
-		if err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				log.Printf("Request to %s timed out", validatedURL)
-			} else {
-				log.Printf("Error sending request: %v", err)
-			}
-			return
-		}
-
-		defer res.Body.Close() // Ensure the response body is closed
-
-		// Read the response
-		payload := &PayLoad{Buf: new(bytes.Buffer)}
-		if _, err := io.Copy(payload.Buf, res.Body); err != nil {
-			log.Printf("Error copying response body: %v", err)
-			return
-		}
-
-		fmt.Printf("Received data: %s\n", payload.Buf.String())
-	}
-}
-
 func main() {
+	dedupTTL := flag.Duration("dedup-ttl", 10*time.Minute, "how long a location's hash is remembered before the same URL may be re-submitted")
+	flag.Parse()
+
 	// Read locations from a file
 	data, err := os.ReadFile("./input.txt")
 	if err != nil {
@@ -129,28 +68,48 @@ func main() {
 		log.Fatal("Error parsing JSON:", err)
 	}
 
-	var wg sync.WaitGroup         // Use a WaitGroup to synchronize goroutines
-	semaphore := NewSemaphore(10) // Limit to 10 concurrent goroutines
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			IdleConnTimeout:     90 * time.Second,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	}
-
-	// Create a base HTTP request
-	baseRequest, err := createBaseRequest(context.Background(), PayLoad{Data: "example data"})
+	h2Client, err := NewClient(ClientConfig{
+		DefaultHostConcurrency: 10,
+	})
 	if err != nil {
-		log.Fatal("Error creating base request:", err)
+		log.Fatal("Error configuring HTTP client:", err)
 	}
+	h2Client.HTTP.Transport = NewCachingTransport(h2Client.HTTP.Transport, 0, 0)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := NewDeliveryQueue()
+	pool := NewSenderPool(queue, SenderPoolConfig{Client: h2Client.HTTP, HostLimiter: h2Client.Limiter})
+	pool.Start(ctx)
+
+	locQueue := NewLocationQueue(len(locations), *dedupTTL)
 	for _, loc := range locations {
-		wg.Add(1)
-		go processLocation(semaphore, &wg, loc, baseRequest, client) // Start a new goroutine for each location
+		if _, err := locQueue.Push(loc); err != nil {
+			log.Printf("Invalid URL: %s", loc.URL)
+		}
 	}
 
-	wg.Wait() // Wait for all goroutines to complete
+	for i := 0; ; i++ {
+		loc, ok := locQueue.Pop()
+		if !ok {
+			break
+		}
+
+		if loc.URL != "https://bar.com" {
+			continue
+		}
+
+		queue.Enqueue(&DeliveryJob{
+			ID:       fmt.Sprintf("%d-%s", i, loc.URL),
+			TargetID: hostOf(loc.URL),
+			URL:      loc.URL,
+			Payload:  PayLoad{Data: "example data"},
+			Ctx:      context.Background(),
+		})
+	}
 
+	queue.WaitIdle() // Wait for every enqueued job to settle
+	cancel()         // Tell the workers to stop waiting for more jobs
+	pool.Stop()
 }
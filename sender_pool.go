@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// hostFailureTracker counts consecutive failures per host and quarantines a
+// host once it reaches threshold failures within window, until cooldown has
+// elapsed.
+type hostFailureTracker struct {
+	mu sync.Mutex
+
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	failures map[string]int
+	lastFail map[string]time.Time
+	badUntil map[string]time.Time
+}
+
+func newHostFailureTracker(threshold int, window, cooldown time.Duration) *hostFailureTracker {
+	return &hostFailureTracker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		failures:  make(map[string]int),
+		lastFail:  make(map[string]time.Time),
+		badUntil:  make(map[string]time.Time),
+	}
+}
+
+// isBad reports whether host is currently quarantined.
+func (t *hostFailureTracker) isBad(host string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.badUntil[host]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(t.badUntil, host)
+		delete(t.failures, host)
+		return false
+	}
+	return true
+}
+
+// recordSuccess clears a host's failure streak.
+func (t *hostFailureTracker) recordSuccess(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, host)
+	delete(t.lastFail, host)
+}
+
+// recordFailure bumps a host's failure streak, resetting it first if the
+// previous failure fell outside the window, and quarantines the host once
+// the streak reaches threshold.
+func (t *hostFailureTracker) recordFailure(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := t.lastFail[host]; ok && now.Sub(last) > t.window {
+		t.failures[host] = 0
+	}
+	t.failures[host]++
+	t.lastFail[host] = now
+
+	if t.failures[host] >= t.threshold {
+		t.badUntil[host] = now.Add(t.cooldown)
+	}
+}
+
+// SenderPoolConfig tunes the sender worker pool.
+type SenderPoolConfig struct {
+	Workers int
+	Client  *http.Client
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	BadHostThreshold int
+	BadHostWindow    time.Duration
+	BadHostCooldown  time.Duration
+
+	// CrashSink receives a report for any panic recovered from a worker's
+	// job loop. A nil value falls back to a DiskCrashSink rooted at
+	// "./crashes".
+	CrashSink CrashSink
+
+	// HostLimiter caps in-flight sends per target host, independent of the
+	// total worker count, so one slow host can't starve the others. A nil
+	// value falls back to 10 slots per host.
+	HostLimiter *HostLimiter
+}
+
+func (c SenderPoolConfig) withDefaults() SenderPoolConfig {
+	if c.Workers <= 0 {
+		c.Workers = runtime.NumCPU()
+	}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.BadHostThreshold <= 0 {
+		c.BadHostThreshold = 5
+	}
+	if c.BadHostWindow <= 0 {
+		c.BadHostWindow = time.Minute
+	}
+	if c.BadHostCooldown <= 0 {
+		c.BadHostCooldown = 2 * time.Minute
+	}
+	if c.CrashSink == nil {
+		c.CrashSink = NewDiskCrashSink("./crashes", 0)
+	}
+	if c.HostLimiter == nil {
+		c.HostLimiter = NewHostLimiter(10, nil)
+	}
+	return c
+}
+
+// SenderPool is a long-lived pool of sender workers that pull jobs off a
+// DeliveryQueue and POST them with the shared HTTP client, retrying
+// transient failures with exponential backoff and quarantining hosts that
+// fail repeatedly.
+type SenderPool struct {
+	cfg   SenderPoolConfig
+	queue *DeliveryQueue
+	hosts *hostFailureTracker
+
+	wg sync.WaitGroup
+}
+
+// NewSenderPool builds a SenderPool draining queue. Zero-valued fields in cfg
+// fall back to sane defaults (Workers defaults to runtime.NumCPU(), minimum
+// 1).
+func NewSenderPool(queue *DeliveryQueue, cfg SenderPoolConfig) *SenderPool {
+	cfg = cfg.withDefaults()
+	return &SenderPool{
+		cfg:   cfg,
+		queue: queue,
+		hosts: newHostFailureTracker(cfg.BadHostThreshold, cfg.BadHostWindow, cfg.BadHostCooldown),
+	}
+}
+
+// Start launches the worker goroutines. Workers run until ctx is cancelled.
+func (p *SenderPool) Start(ctx context.Context) {
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker(ctx, i)
+	}
+}
+
+// Stop waits for all workers to return. Callers should cancel the context
+// passed to Start (or drain the queue) before calling Stop.
+func (p *SenderPool) Stop() {
+	p.wg.Wait()
+}
+
+func (p *SenderPool) worker(ctx context.Context, id int) {
+	defer p.wg.Done()
+
+	for {
+		job, ok := p.queue.Wait(ctx)
+		if !ok {
+			return
+		}
+		RecoverMiddleware(p.cfg.CrashSink, id, job.ID, func() {
+			p.handle(ctx, id, job)
+		})
+	}
+}
+
+// handle sends job, re-enqueueing it with backoff on transient failure or
+// settling it (success, dropped for a bad host, or attempts exhausted).
+func (p *SenderPool) handle(parent context.Context, workerID int, job *DeliveryJob) {
+	jobCtx := job.Ctx
+	if jobCtx == nil {
+		jobCtx = parent
+	}
+
+	host := hostOf(job.URL)
+	if p.hosts.isBad(host) {
+		logJobEvent(slog.LevelWarn, "dropping job, host is quarantined", workerID, job.ID, host, job.Attempt, nil)
+		p.queue.markSettled()
+		return
+	}
+
+	req, err := createBaseRequest(jobCtx, job.Payload)
+	if err != nil {
+		logJobEvent(slog.LevelError, "building request", workerID, job.ID, host, job.Attempt, err)
+		p.queue.markSettled()
+		return
+	}
+	req.URL, err = url.Parse(job.URL)
+	if err != nil {
+		logJobEvent(slog.LevelError, "invalid job URL", workerID, job.ID, host, job.Attempt, err)
+		p.queue.markSettled()
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(jobCtx, 5*time.Second)
+	defer cancel()
+
+	if err := p.cfg.HostLimiter.Acquire(sendCtx, host); err != nil {
+		p.retryOrDrop(workerID, job, host, err)
+		return
+	}
+	res, err := p.cfg.Client.Do(req.WithContext(sendCtx))
+	p.cfg.HostLimiter.Release(host)
+	if err != nil {
+		p.retryOrDrop(workerID, job, host, err)
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		p.retryOrDrop(workerID, job, host, fmt.Errorf("status %d", res.StatusCode))
+		return
+	}
+
+	payload := &PayLoad{Buf: new(bytes.Buffer)}
+	if _, err := io.Copy(payload.Buf, res.Body); err != nil {
+		logJobEvent(slog.LevelError, "reading response body", workerID, job.ID, host, job.Attempt, err)
+	} else {
+		fmt.Printf("Received data: %s\n", payload.Buf.String())
+	}
+
+	p.hosts.recordSuccess(host)
+	p.queue.markSettled()
+}
+
+func (p *SenderPool) retryOrDrop(workerID int, job *DeliveryJob, host string, cause error) {
+	p.hosts.recordFailure(host)
+
+	if job.Attempt+1 >= p.cfg.MaxAttempts {
+		logJobEvent(slog.LevelError, "job exhausted max attempts", workerID, job.ID, host, job.Attempt+1, cause)
+		p.queue.markSettled()
+		return
+	}
+
+	next := *job
+	next.Attempt = job.Attempt + 1
+	backoff := backoffDuration(p.cfg.BaseBackoff, p.cfg.MaxBackoff, next.Attempt)
+
+	logJobEvent(slog.LevelWarn, fmt.Sprintf("job failed, retrying in %s", backoff), workerID, job.ID, host, job.Attempt, cause)
+	time.AfterFunc(backoff, func() {
+		p.queue.Enqueue(&next)
+	})
+}
+
+// backoffDuration computes the exponential backoff before attempt, capped
+// at max.
+func backoffDuration(base, max time.Duration, attempt int) time.Duration {
+	d := base * time.Duration(math.Pow(2, float64(attempt-1)))
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// hostOf returns the host component of rawURL, or rawURL itself if it
+// cannot be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostFailureTrackerQuarantineAndCooldown(t *testing.T) {
+	tr := newHostFailureTracker(3, 50*time.Millisecond, 40*time.Millisecond)
+
+	if tr.isBad("flappy.example") {
+		t.Fatal("host should not be bad before any failures")
+	}
+
+	tr.recordFailure("flappy.example")
+	tr.recordFailure("flappy.example")
+	if tr.isBad("flappy.example") {
+		t.Fatal("host should not be quarantined below the failure threshold")
+	}
+
+	tr.recordFailure("flappy.example")
+	if !tr.isBad("flappy.example") {
+		t.Fatal("host should be quarantined once the failure threshold is reached")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if tr.isBad("flappy.example") {
+		t.Fatal("host should recover once the cooldown has elapsed")
+	}
+}
+
+func TestHostFailureTrackerRecordSuccessResetsStreak(t *testing.T) {
+	tr := newHostFailureTracker(2, time.Second, time.Second)
+
+	tr.recordFailure("host")
+	tr.recordSuccess("host")
+	tr.recordFailure("host")
+	if tr.isBad("host") {
+		t.Fatal("a single failure after a success should not quarantine the host")
+	}
+}
+
+func TestBackoffDurationCapsAtMax(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 35 * time.Millisecond
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, max}, // 40ms would exceed max, so it's capped
+		{4, max},
+	}
+	for _, c := range cases {
+		if got := backoffDuration(base, max, c.attempt); got != c.want {
+			t.Errorf("backoffDuration(attempt=%d) = %s, want %s", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestDeliveryQueueCancelTarget(t *testing.T) {
+	q := NewDeliveryQueue()
+	q.Enqueue(&DeliveryJob{ID: "a1", TargetID: "host-a"})
+	q.Enqueue(&DeliveryJob{ID: "a2", TargetID: "host-a"})
+	q.Enqueue(&DeliveryJob{ID: "b1", TargetID: "host-b"})
+
+	if removed := q.CancelTarget("host-a"); removed != 2 {
+		t.Fatalf("CancelTarget(host-a) removed %d jobs, want 2", removed)
+	}
+
+	job, ok := q.pop()
+	if !ok || job.ID != "b1" {
+		t.Fatalf("pop() = %v, %v, want b1, true", job, ok)
+	}
+
+	if _, ok := q.pop(); ok {
+		t.Fatal("expected no more pending jobs after cancelling host-a")
+	}
+}
+
+// TestSenderPoolRetriesThenSucceeds simulates a flapping host that fails
+// twice before succeeding, and asserts the job is retried with backoff
+// rather than dropped.
+func TestSenderPoolRetriesThenSucceeds(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	queue := NewDeliveryQueue()
+	pool := NewSenderPool(queue, SenderPoolConfig{
+		Client:      srv.Client(),
+		MaxAttempts: 5,
+		BaseBackoff: 2 * time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Start(ctx)
+
+	queue.Enqueue(&DeliveryJob{
+		ID:       "job-1",
+		TargetID: hostOf(srv.URL),
+		URL:      srv.URL,
+		Ctx:      context.Background(),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		queue.WaitIdle()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the flapping job to settle")
+	}
+
+	cancel()
+	pool.Stop()
+
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("server received %d requests, want 3 (2 failures + 1 success)", got)
+	}
+}
+
+// TestSenderPoolQuarantinesBadHost simulates a host that always fails and
+// asserts that once the failure threshold is crossed, further attempts are
+// dropped locally instead of hitting the server again.
+func TestSenderPoolQuarantinesBadHost(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	queue := NewDeliveryQueue()
+	pool := NewSenderPool(queue, SenderPoolConfig{
+		Client:           srv.Client(),
+		MaxAttempts:      3,
+		BaseBackoff:      2 * time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		BadHostThreshold: 2,
+		BadHostWindow:    time.Second,
+		BadHostCooldown:  time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Start(ctx)
+
+	queue.Enqueue(&DeliveryJob{
+		ID:       "job-1",
+		TargetID: hostOf(srv.URL),
+		URL:      srv.URL,
+		Ctx:      context.Background(),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		queue.WaitIdle()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the job against the bad host to settle")
+	}
+
+	cancel()
+	pool.Stop()
+
+	// The host is quarantined after its 2nd failure, so the 3rd attempt
+	// must be dropped locally rather than reaching the server.
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Fatalf("server received %d requests, want 2 before quarantine kicked in", got)
+	}
+}
+
+func TestRetryOrDropExhaustsAttempts(t *testing.T) {
+	queue := NewDeliveryQueue()
+	pool := NewSenderPool(queue, SenderPoolConfig{
+		MaxAttempts: 2,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+
+	job := &DeliveryJob{ID: "job-1", TargetID: "host"}
+	queue.Enqueue(job)
+	queue.pop()
+	job.Attempt = 1 // pretend this job already failed once
+
+	pool.retryOrDrop(0, job, "host", errors.New("boom"))
+
+	done := make(chan struct{})
+	go func() {
+		queue.WaitIdle()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job should have settled once attempts were exhausted")
+	}
+
+	if _, ok := queue.pop(); ok {
+		t.Fatal("an exhausted job should not be re-enqueued")
+	}
+}